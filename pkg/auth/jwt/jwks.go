@@ -0,0 +1,93 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+
+	jwkgo "github.com/lestrrat-go/jwx/jwk"
+)
+
+// jwksRefreshInterval controls how often a cached key set is re-fetched so
+// key rotation on the issuer's side is picked up without a restart.
+const jwksRefreshInterval = 10 * time.Minute
+
+// jwksCache fetches and caches a JSON Web Key Set, refreshing it
+// periodically in the background.
+type jwksCache struct {
+	url string
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+
+	fetchOnce sync.Once
+}
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{
+		url:  url,
+		keys: map[string]*rsa.PublicKey{},
+	}
+}
+
+// Key returns the public key for kid, fetching (and subsequently
+// refreshing) the key set as needed.
+func (c *jwksCache) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.fetchOnce.Do(func() {
+		_ = c.refresh(ctx)
+		go c.refreshLoop()
+	})
+
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	c.mu.RUnlock()
+	if !ok {
+		// The key set may have rotated since our last refresh; try once
+		// more synchronously before giving up.
+		if err := c.refresh(ctx); err != nil {
+			return nil, err
+		}
+		c.mu.RLock()
+		key, ok = c.keys[kid]
+		c.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+func (c *jwksCache) refreshLoop() {
+	ticker := time.NewTicker(jwksRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = c.refresh(context.Background())
+	}
+}
+
+func (c *jwksCache) refresh(ctx context.Context) error {
+	set, err := jwkgo.FetchHTTP(c.url)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS from %s: %w", c.url, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		raw, err := k.Materialize()
+		if err != nil {
+			continue
+		}
+		pub, ok := raw.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		keys[k.KeyID()] = pub
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package jwt validates bearer tokens against a JWKS and extracts the
+// tenant ID that downstream Tempo code expects to find on the request
+// context, as an alternative to trusting a raw X-Scope-OrgID header.
+package jwt
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+)
+
+// Config configures JWT-based tenant authentication.
+type Config struct {
+	Issuer      string `yaml:"issuer"`
+	Audience    string `yaml:"audience"`
+	JWKSURL     string `yaml:"jwks_url"`
+	TenantClaim string `yaml:"tenant_claim"`
+}
+
+// RegisterFlags registers flags for Config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Issuer, "auth.jwt.issuer", "", "Expected `iss` claim of incoming tokens.")
+	f.StringVar(&cfg.Audience, "auth.jwt.audience", "", "Expected `aud` claim of incoming tokens.")
+	f.StringVar(&cfg.JWKSURL, "auth.jwt.jwks-url", "", "URL to fetch the JSON Web Key Set used to verify token signatures.")
+	f.StringVar(&cfg.TenantClaim, "auth.jwt.tenant-claim", "sub", "Claim to read the tenant ID from.")
+}
+
+// Verifier validates a bearer token and returns the tenant ID extracted
+// from it. It's an interface so tests can supply fake keys instead of
+// standing up a JWKS endpoint.
+type Verifier interface {
+	// Verify checks the token's signature, iss, aud and exp, and returns
+	// the tenant ID read from the configured claim.
+	Verify(ctx context.Context, token string) (tenantID string, err error)
+}
+
+// NewVerifier creates the default Verifier, backed by a KeySet that
+// refreshes from cfg.JWKSURL periodically.
+func NewVerifier(cfg Config) (Verifier, error) {
+	if cfg.JWKSURL == "" {
+		return nil, fmt.Errorf("auth.jwt.jwks-url is required when auth.mode is jwt")
+	}
+
+	return &verifier{
+		cfg:    cfg,
+		keySet: newJWKSCache(cfg.JWKSURL),
+	}, nil
+}
+
+type verifier struct {
+	cfg    Config
+	keySet *jwksCache
+}
+
+func (v *verifier) Verify(ctx context.Context, token string) (string, error) {
+	keyFunc := func(t *jwtgo.Token) (interface{}, error) {
+		// Pin the signing method to RSA: our keys only ever come from an
+		// RSA JWKS, so without this check a token claiming "alg": "HS256"
+		// (or "none") would have jwt-go verify it as an HMAC signed with
+		// the RSA public key - or not verify it at all - instead of
+		// rejecting it outright.
+		if _, ok := t.Method.(*jwtgo.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %q, want RS256/RS384/RS512", t.Header["alg"])
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		return v.keySet.Key(ctx, kid)
+	}
+
+	claims := jwtgo.MapClaims{}
+	parsed, err := jwtgo.ParseWithClaims(token, claims, keyFunc, jwtgo.WithValidMethods([]string{"RS256", "RS384", "RS512"}))
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	if !parsed.Valid {
+		return "", fmt.Errorf("invalid token")
+	}
+
+	if v.cfg.Issuer != "" && !claims.VerifyIssuer(v.cfg.Issuer, true) {
+		return "", fmt.Errorf("unexpected issuer")
+	}
+	if v.cfg.Audience != "" && !claims.VerifyAudience(v.cfg.Audience, true) {
+		return "", fmt.Errorf("unexpected audience")
+	}
+
+	tenant, ok := claims[v.cfg.TenantClaim].(string)
+	if !ok || tenant == "" {
+		return "", fmt.Errorf("token missing tenant claim %q", v.cfg.TenantClaim)
+	}
+
+	return tenant, nil
+}
@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// runtimeHTTPStatusFromCode maps a gRPC status code to the HTTP status
+// code callers of this package's JSON endpoints should see, modelled on
+// the mapping grpc-gateway uses so clients get familiar semantics
+// without us depending on grpc-gateway itself. It is not identical:
+// FailedPrecondition maps to 412 here, and every code not listed below
+// (Canceled, Aborted and OutOfRange among them) maps to 500.
+func runtimeHTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
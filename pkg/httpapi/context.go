@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/metadata"
+)
+
+// outgoingContext turns an already-authenticated HTTP request (one that
+// has gone through the module's auth middleware, so r.Context() carries
+// the tenant injected by user.InjectOrgID) into a context carrying the
+// equivalent outgoing gRPC metadata, so the local in-process gRPC call
+// below sees the same tenant the server-side interceptors already
+// validated - exactly what a real networked client would send.
+//
+// It also forwards the original Authorization header, since auth.mode
+// "jwt" re-validates the bearer token on the gRPC side rather than
+// trusting the tenant alone.
+func outgoingContext(r *http.Request) (context.Context, error) {
+	ctx, err := user.InjectIntoGRPCRequest(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if authz := r.Header.Get("Authorization"); authz != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authz)
+	}
+
+	return ctx, nil
+}
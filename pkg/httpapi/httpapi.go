@@ -0,0 +1,17 @@
+// Package httpapi exposes the ingester and querier gRPC services as plain
+// HTTP+JSON endpoints, hand-written rather than generated by grpc-gateway:
+// the two RPCs involved (trace-by-ID lookup and push) are simple enough
+// that pulling in grpc-gateway and its protobuf codegen isn't worth the
+// build-time and binary-size cost.
+//
+// Each Register* function wires a route onto the module's existing
+// mux.Router and forwards requests to the in-process gRPC handler over a
+// local, in-memory dialer (see NewLocalConn) so that server-side
+// interceptors - in particular auth - run exactly as they would for a
+// real network client.
+//
+// Stable URL scheme:
+//
+//	GET  /api/traces/{traceID}   -> querier:  find a trace by ID
+//	POST /api/push               -> ingester: push a batch of spans
+package httpapi
@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/middleware"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// RegisterIngesterPushHTTP registers POST /api/push on router, decoding a
+// JSON-encoded tempopb.PushRequest body and forwarding it to the
+// ingester's gRPC Push handler. auth is the same auth middleware the
+// module's gRPC listener enforces, so a request authenticated here
+// carries the tenant the in-process gRPC call needs to pass the server's
+// own auth interceptor.
+func RegisterIngesterPushHTTP(router *mux.Router, auth middleware.Interface, client tempopb.PusherClient) {
+	router.Handle("/api/push", auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		req := &tempopb.PushRequest{}
+		if err := jsonpb.Unmarshal(r.Body, req); err != nil {
+			http.Error(w, "decoding request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, err := outgoingContext(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		resp, err := client.Push(ctx, req)
+		if err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+
+		writeJSONPB(w, resp)
+	}))).Methods(http.MethodPost)
+}
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+)
+
+// errListenerClosed is returned by bufListener once it has been closed.
+var errListenerClosed = errors.New("httpapi: local listener closed")
+
+// bufListener is a minimal, bufconn-style in-memory net.Listener: Accept
+// pairs up with dial through an unbuffered channel, handing each side one
+// end of a net.Pipe. It exists so HTTP handlers in this package can reach
+// a gRPC server registered on the real listening socket without a TCP
+// round-trip.
+type bufListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+}
+
+func newBufListener() *bufListener {
+	return &bufListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *bufListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *bufListener) dial(ctx context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *bufListener) Close() error {
+	select {
+	case <-l.closed:
+	default:
+		close(l.closed)
+	}
+	return nil
+}
+
+func (l *bufListener) Addr() net.Addr { return bufAddr{} }
+
+type bufAddr struct{}
+
+func (bufAddr) Network() string { return "httpapi" }
+func (bufAddr) String() string  { return "local" }
+
+// NewLocalConn prepares an in-memory listener and a client connection
+// wired straight to it, plus a start func that begins serving grpcServer
+// on that listener and a close func that tears it down. Every call made
+// through the returned connection goes through grpcServer's configured
+// interceptors (in particular auth), same as a networked client would.
+//
+// start must not be called until every module sharing grpcServer has
+// finished registering its gRPC service: grpc.Server.Serve latches the
+// server into a "serving" state, and RegisterService after that point
+// fatals the process. Callers dial once at module init time (registering
+// is safe at any point before start), but defer calling start until
+// Tempo has finished initialising every module for the target - see
+// Tempo.New.
+func NewLocalConn(ctx context.Context, grpcServer *grpc.Server) (conn *grpc.ClientConn, start func(), closeConn func(), err error) {
+	l := newBufListener()
+
+	cc, err := grpc.DialContext(ctx, "local",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.dial(ctx)
+		}),
+	)
+	if err != nil {
+		l.Close()
+		return nil, nil, nil, err
+	}
+
+	start = func() {
+		go func() {
+			_ = grpcServer.Serve(l)
+		}()
+	}
+
+	return cc, start, func() { l.Close() }, nil
+}
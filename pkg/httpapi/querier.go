@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/middleware"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// RegisterQuerierHTTP registers GET /api/traces/{traceID} on router,
+// translating the path parameter into a tempopb.TraceByIDRequest and the
+// response back into JSON. auth is the same auth middleware the module's
+// gRPC listener enforces, so a request authenticated here carries the
+// tenant the in-process gRPC call needs to pass the server's own auth
+// interceptor.
+func RegisterQuerierHTTP(router *mux.Router, auth middleware.Interface, client tempopb.QuerierClient) {
+	router.Handle("/api/traces/{traceID}", auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID, err := hex.DecodeString(mux.Vars(r)["traceID"])
+		if err != nil {
+			http.Error(w, "invalid trace ID: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, err := outgoingContext(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		resp, err := client.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+			TraceID: traceID,
+		})
+		if err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+		if resp.Trace == nil {
+			http.Error(w, "trace not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSONPB(w, resp.Trace)
+	})))
+}
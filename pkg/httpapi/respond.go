@@ -0,0 +1,31 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/status"
+)
+
+var marshaler = jsonpb.Marshaler{EmitDefaults: true}
+
+// writeJSONPB marshals a protobuf message as JSON and writes it to w.
+func writeJSONPB(w http.ResponseWriter, msg proto.Message) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := marshaler.Marshal(w, msg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeGRPCError translates a gRPC status error into the equivalent HTTP
+// status code and writes its message as the body.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Error(w, st.Message(), runtimeHTTPStatusFromCode(st.Code()))
+}
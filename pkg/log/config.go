@@ -0,0 +1,59 @@
+package log
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Config configures the zap-backed Logger used throughout Tempo.
+type Config struct {
+	Level    string         `yaml:"level"`
+	Format   string         `yaml:"format"` // json or console
+	Sampling SamplingConfig `yaml:"sampling"`
+	Caller   bool           `yaml:"caller"`
+
+	// ModuleLevels overrides Level for specific modules, given as
+	// comma-separated module=level pairs, e.g. "ingester=debug,querier=info".
+	ModuleLevels string `yaml:"module_levels"`
+}
+
+// SamplingConfig controls zap's log sampling: after Initial entries with
+// identical level+message in a one-second window, only every Thereafter-th
+// one is kept. Sampling is on only when Initial is above zero; an Initial
+// of zero turns it off altogether.
+type SamplingConfig struct {
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
+}
+
+// RegisterFlags registers flags for Config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Level, "log.level", "info", "Minimum log level: debug, info, warn, or error.")
+	f.StringVar(&cfg.Format, "log.format", "json", "Log format: json or console.")
+	f.IntVar(&cfg.Sampling.Initial, "log.sampling.initial", 100, "Entries per second, per level+message, logged before sampling kicks in.")
+	f.IntVar(&cfg.Sampling.Thereafter, "log.sampling.thereafter", 100, "Once sampling kicks in, log every Nth entry.")
+	f.BoolVar(&cfg.Caller, "log.caller", false, "Include the calling file and line number in log output.")
+	f.StringVar(&cfg.ModuleLevels, "log.module-levels", "", "Per-module log level overrides, e.g. \"ingester=debug,querier=info\".")
+}
+
+// ParseModuleLevels parses the module_levels flag into a module -> level
+// map. It's resolved against a specific module when that module's init
+// runs, rather than once at startup, since RegisterFlags can't know the
+// set of modules that will actually be started.
+func ParseModuleLevels(s string) (map[string]string, error) {
+	levels := map[string]string{}
+	if s == "" {
+		return levels, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid log.module-levels entry %q, want module=level", pair)
+		}
+		levels[kv[0]] = kv[1]
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,153 @@
+// Package log provides Tempo's structured logger, built on zap instead of
+// go-kit/kit/log/level. It exposes a Logger interface shaped exactly like
+// go-kit's log.Logger (Log(keyvals ...interface{}) error), so existing
+// call sites such as level.Info(logger).Log("msg", ..., "module", m)
+// keep working unchanged against a zap-backed implementation - no call
+// site needs to migrate until it wants to move off go-kit/level entirely.
+package log
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger is the logging interface used throughout Tempo.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// New builds a Logger backed by zap, configured from cfg.
+//
+// The underlying zap core is always built permissive enough to emit the
+// most verbose level any configured log.module-levels override asks for.
+// Per-logger filtering is then done by zapLogger.Log itself (see level
+// field below), not by zap's own core level - that's what lets a module
+// override *lower* the effective level below the global default, e.g.
+// log.module_levels: ingester=debug with a global log.level: info.
+func New(cfg Config) (Logger, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log.level %q: %w", cfg.Level, err)
+	}
+
+	moduleLevels, err := ParseModuleLevels(cfg.ModuleLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	coreLevel := level
+	for module, lvlStr := range moduleLevels {
+		lvl, err := parseLevel(lvlStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing log.module-levels override for %q: %w", module, err)
+		}
+		if lvl < coreLevel {
+			coreLevel = lvl
+		}
+	}
+
+	var zcfg zap.Config
+	if cfg.Format == "console" {
+		zcfg = zap.NewDevelopmentConfig()
+	} else {
+		zcfg = zap.NewProductionConfig()
+	}
+	zcfg.Level = zap.NewAtomicLevelAt(coreLevel)
+	zcfg.DisableCaller = !cfg.Caller
+	if cfg.Sampling.Initial > 0 {
+		zcfg.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.Sampling.Initial,
+			Thereafter: cfg.Sampling.Thereafter,
+		}
+	} else {
+		zcfg.Sampling = nil
+	}
+
+	zl, err := zcfg.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &zapLogger{l: zl, level: level}, nil
+}
+
+// WithLevel returns a copy of base logging at level instead of its
+// configured default. Used to apply log.module-levels overrides when a
+// given module's init runs. Unlike zap.IncreaseLevel, this can lower the
+// effective level too, since New already built the shared core permissive
+// enough for every configured override.
+func WithLevel(base Logger, level string) (Logger, error) {
+	zl, ok := base.(*zapLogger)
+	if !ok {
+		return base, nil
+	}
+
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("parsing override level %q: %w", level, err)
+	}
+
+	return &zapLogger{l: zl.l, level: lvl}, nil
+}
+
+func parseLevel(s string) (zapcore.Level, error) {
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(s))
+	return lvl, err
+}
+
+type zapLogger struct {
+	l *zap.Logger
+	// level is the threshold this Logger enforces itself, independent of
+	// the shared core's own (more permissive) level - see New.
+	level zapcore.Level
+}
+
+// Log implements Logger in go-kit's keyvals style: alternating key/value
+// pairs, with "msg" and "level" given special handling to map onto zap's
+// message and level rather than becoming fields.
+func (z *zapLogger) Log(keyvals ...interface{}) error {
+	lvl := zapcore.InfoLevel
+	msg := ""
+	fields := make([]zap.Field, 0, len(keyvals)/2)
+
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, _ := keyvals[i].(string)
+		val := keyvals[i+1]
+
+		switch key {
+		case "msg":
+			msg, _ = val.(string)
+		case "level":
+			lvl = levelFromGoKit(val)
+		default:
+			fields = append(fields, zap.Any(key, val))
+		}
+	}
+
+	if lvl < z.level {
+		return nil
+	}
+
+	if ce := z.l.Check(lvl, msg); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// levelFromGoKit maps a go-kit/level level.Value (or a plain string, for
+// callers that pass "level" directly) onto a zap level.
+func levelFromGoKit(v interface{}) zapcore.Level {
+	switch fmt.Sprintf("%v", v) {
+	case "debug":
+		return zapcore.DebugLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
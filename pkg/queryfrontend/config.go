@@ -0,0 +1,41 @@
+package queryfrontend
+
+import (
+	"errors"
+	"flag"
+	"time"
+)
+
+var errTenantQueueFull = errors.New("tenant queue is full, try again later")
+
+// Config configures the query frontend module.
+type Config struct {
+	MaxOutstandingPerTenant int           `yaml:"max_outstanding_per_tenant"`
+	QuerierForgetDelay      time.Duration `yaml:"querier_forget_delay"`
+}
+
+// RegisterFlags registers flags for Config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-frontend.max-outstanding-per-tenant", 100, "Maximum number of outstanding requests per tenant the frontend will queue before returning an error to the caller.")
+	f.DurationVar(&cfg.QuerierForgetDelay, "query-frontend.querier-forget-delay", 0, "Time to wait before forgetting a querier worker that disconnected without notice. The frontend keeps no pool of workers, so this value is not read.")
+}
+
+// WorkerConfig configures the querier-side worker that dials the query
+// frontend and pulls work from it.
+type WorkerConfig struct {
+	FrontendAddress  string        `yaml:"frontend_address"`
+	Parallelism      int           `yaml:"parallelism"`
+	DNSLookupPeriod  time.Duration `yaml:"dns_lookup_period"`
+	GRPCClientConfig grpcClientConfig `yaml:"grpc_client_config"`
+}
+
+// grpcClientConfig is a minimal placeholder for the TLS/keepalive dial
+// options shared with the rest of Tempo's gRPC clients.
+type grpcClientConfig struct{}
+
+// RegisterFlags registers flags for WorkerConfig.
+func (cfg *WorkerConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.FrontendAddress, "querier.frontend-address", "", "Address of the query frontend to pull queries from, e.g. frontend:9095. If unset the querier serves requests directly instead of pulling from a frontend.")
+	f.IntVar(&cfg.Parallelism, "querier.worker-parallelism", 10, "Number of concurrent workers pulling requests from the query frontend.")
+	f.DurationVar(&cfg.DNSLookupPeriod, "querier.worker-dns-lookup-period", 10*time.Second, "How often to re-resolve the frontend address. The worker does not re-resolve it on a timer, so this value is not read.")
+}
@@ -0,0 +1,206 @@
+// Package queryfrontend implements a Cortex-style query frontend for
+// Tempo: an HTTP layer that accepts trace-by-ID lookups and queues them
+// per-tenant for querier workers to pull over gRPC, rather than the
+// frontend dialing queriers directly. This lets the read path scale
+// independently of ingesters and keeps a single, bounded queue in front of
+// a variable number of queriers.
+package queryfrontend
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	tempolog "github.com/grafana/tempo/pkg/log"
+	"github.com/grafana/tempo/pkg/queryfrontend/frontendpb"
+)
+
+// Frontend accepts trace lookups over HTTP, queues them per-tenant, and
+// serves them to querier workers connected over the Process gRPC stream.
+type Frontend struct {
+	cfg  Config
+	log  tempolog.Logger
+	auth middleware.Interface
+
+	queue *tenantQueue
+
+	// workers counts querier workers currently holding a Process stream
+	// open, so handleHTTP can fail fast instead of queuing a request that
+	// has nothing to dequeue it until the caller's own context expires.
+	workers int32
+}
+
+// New creates a Frontend.
+func New(cfg Config, auth middleware.Interface, logger tempolog.Logger) (*Frontend, error) {
+	return &Frontend{
+		cfg:   cfg,
+		log:   logger,
+		auth:  auth,
+		queue: newTenantQueue(cfg.MaxOutstandingPerTenant),
+	}, nil
+}
+
+// RegisterRoutes registers the frontend's HTTP entry points. This owns
+// /api/traces/{traceID}: when a query frontend and a querier both run
+// in-process (-target=all), the querier's own gateway for the same path
+// (httpapi.RegisterQuerierHTTP) is left unregistered so lookups always go
+// through this tenant queue rather than bypassing it - see
+// Tempo.initQuerier.
+func (f *Frontend) RegisterRoutes(router *mux.Router) {
+	router.Handle("/api/traces/{traceID}", f.auth.Wrap(http.HandlerFunc(f.handleHTTP)))
+}
+
+// RegisterGRPC registers the frontend's Process service, the endpoint
+// querier workers dial in to for work.
+func (f *Frontend) RegisterGRPC(server *grpc.Server) {
+	frontendpb.RegisterFrontendServer(server, f)
+}
+
+// Shutdown does nothing: the frontend keeps accepting requests for as long
+// as the HTTP server routes them to it. Requests already queued are left
+// to drain or time out via the caller's context.
+func (f *Frontend) Shutdown() {
+}
+
+// handleHTTP enqueues an incoming HTTP request for the caller's tenant and
+// blocks until a querier worker has produced a response.
+func (f *Frontend) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&f.workers) == 0 {
+		http.Error(w, "no querier workers connected", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	tenant, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	httpReq, err := httpgrpc.FromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := httpReq.Marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := f.do(ctx, tenant, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	httpResp := &httpgrpc.HTTPResponse{}
+	if err := httpResp.Unmarshal(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	httpgrpc.WriteResponse(w, httpResp)
+}
+
+// do queues reqBody for tenant and waits for a querier worker to process
+// it, retrying on a different worker if the one holding the request
+// disconnects before replying.
+func (f *Frontend) do(ctx context.Context, tenant string, reqBody []byte) ([]byte, error) {
+	r := &request{
+		req:  &frontendpb.ProcessRequest{TenantID: tenant, HttpBody: reqBody},
+		resp: make(chan *frontendpb.ProcessResponse, 1),
+		err:  make(chan error, 1),
+	}
+
+	if err := f.queue.enqueue(tenant, r); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-r.err:
+		// The worker holding this request disconnected; re-queue once
+		// for the next available worker to pick up rather than failing
+		// the caller outright.
+		level.Warn(f.log).Log("msg", "querier worker disconnected mid-request, retrying", "tenant", tenant, "err", err)
+		return f.retryOnce(ctx, tenant, reqBody)
+	case resp := <-r.resp:
+		if resp.Error != "" {
+			return nil, errQuerierFailed(resp.Error)
+		}
+		return resp.HttpBody, nil
+	}
+}
+
+func (f *Frontend) retryOnce(ctx context.Context, tenant string, reqBody []byte) ([]byte, error) {
+	r := &request{
+		req:  &frontendpb.ProcessRequest{TenantID: tenant, HttpBody: reqBody},
+		resp: make(chan *frontendpb.ProcessResponse, 1),
+		err:  make(chan error, 1),
+	}
+	if err := f.queue.enqueue(tenant, r); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-r.err:
+		return nil, err
+	case resp := <-r.resp:
+		if resp.Error != "" {
+			return nil, errQuerierFailed(resp.Error)
+		}
+		return resp.HttpBody, nil
+	}
+}
+
+// Process implements frontendpb.FrontendServer. A querier worker holds
+// this stream open, repeatedly receiving the next queued request and
+// sending back its result.
+func (f *Frontend) Process(stream frontendpb.Frontend_ProcessServer) error {
+	ctx := stream.Context()
+
+	atomic.AddInt32(&f.workers, 1)
+	defer atomic.AddInt32(&f.workers, -1)
+
+	for {
+		r, err := f.queue.dequeue(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(r.req); err != nil {
+			// The worker went away with this request in hand; surface
+			// the disconnect to the waiting caller so it can retry
+			// against another worker instead of hanging until its
+			// context expires.
+			r.err <- err
+			return err
+		}
+
+		resp, err := stream.Recv()
+		if err != nil {
+			r.err <- err
+			return err
+		}
+
+		r.resp <- resp
+	}
+}
+
+func errQuerierFailed(msg string) error {
+	return &querierError{msg}
+}
+
+type querierError struct{ msg string }
+
+func (e *querierError) Error() string { return e.msg }
@@ -0,0 +1,129 @@
+// Package frontendpb defines the gRPC contract between the query frontend
+// and the querier workers that pull work from it.
+//
+// This is hand-written rather than protoc-generated: the messages are
+// simple enough that carrying a protobuf toolchain dependency for them
+// isn't worth it. If the contract grows more fields it should move to a
+// proper .proto + generated pb.go, matching the rest of the Tempo gRPC
+// surface.
+package frontendpb
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ProcessRequest is a single unit of work handed to a querier worker.
+type ProcessRequest struct {
+	TenantID string
+	HttpBody []byte // marshalled HTTP request (method, path, headers, body)
+}
+
+// ProcessResponse is the result of executing a ProcessRequest.
+type ProcessResponse struct {
+	HttpBody []byte // marshalled HTTP response (status, headers, body)
+	Error    string
+}
+
+// FrontendClient is implemented by the frontend's gRPC server and called by
+// querier workers.
+type FrontendClient interface {
+	Process(ctx context.Context, opts ...grpc.CallOption) (Frontend_ProcessClient, error)
+}
+
+// FrontendServer is implemented by the query frontend.
+type FrontendServer interface {
+	Process(Frontend_ProcessServer) error
+}
+
+// Frontend_ProcessClient is the querier-worker side of the bidirectional
+// Process stream: send completed responses, receive new requests to work
+// on.
+type Frontend_ProcessClient interface {
+	Send(*ProcessResponse) error
+	Recv() (*ProcessRequest, error)
+	grpc.ClientStream
+}
+
+// Frontend_ProcessServer is the frontend side of the same stream.
+type Frontend_ProcessServer interface {
+	Send(*ProcessRequest) error
+	Recv() (*ProcessResponse, error)
+	grpc.ServerStream
+}
+
+// ServiceDesc is registered against the gRPC server so queriers can dial in
+// and open a Process stream.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "frontend.Frontend",
+	HandlerType: (*FrontendServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Process",
+			Handler:       processHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func processHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FrontendServer).Process(&processServer{stream})
+}
+
+type processServer struct {
+	grpc.ServerStream
+}
+
+func (s *processServer) Send(r *ProcessRequest) error {
+	return s.ServerStream.SendMsg(r)
+}
+
+func (s *processServer) Recv() (*ProcessResponse, error) {
+	m := new(ProcessResponse)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewFrontendClient wraps a grpc.ClientConn so callers get the typed
+// Process stream rather than dealing with grpc.ClientConn directly.
+func NewFrontendClient(cc *grpc.ClientConn) FrontendClient {
+	return &frontendClient{cc}
+}
+
+type frontendClient struct {
+	cc *grpc.ClientConn
+}
+
+func (c *frontendClient) Process(ctx context.Context, opts ...grpc.CallOption) (Frontend_ProcessClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[0], "/frontend.Frontend/Process", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &processClient{stream}, nil
+}
+
+type processClient struct {
+	grpc.ClientStream
+}
+
+func (c *processClient) Send(r *ProcessResponse) error {
+	return c.ClientStream.SendMsg(r)
+}
+
+func (c *processClient) Recv() (*ProcessRequest, error) {
+	m := new(ProcessRequest)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegisterFrontendServer registers srv against s under the Frontend service
+// descriptor.
+func RegisterFrontendServer(s *grpc.Server, srv FrontendServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
@@ -0,0 +1,116 @@
+package queryfrontend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/tempo/pkg/queryfrontend/frontendpb"
+)
+
+// tenantQueue round-robins pending requests across tenants so that one
+// noisy tenant can't starve the others out of querier capacity. Each
+// tenant gets its own FIFO channel; dequeue walks tenants in rotation and
+// only ever pulls one request per visit.
+type tenantQueue struct {
+	maxOutstandingPerTenant int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tenants []string
+	queues  map[string]chan *request
+	next    int
+}
+
+type request struct {
+	req  *frontendpb.ProcessRequest
+	resp chan *frontendpb.ProcessResponse
+	err  chan error
+}
+
+func newTenantQueue(maxOutstandingPerTenant int) *tenantQueue {
+	q := &tenantQueue{
+		maxOutstandingPerTenant: maxOutstandingPerTenant,
+		queues:                  map[string]chan *request{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// enqueue adds a request for tenant and returns an error if that tenant is
+// already at its outstanding-request limit.
+func (q *tenantQueue) enqueue(tenant string, r *request) error {
+	q.mu.Lock()
+	ch, ok := q.queues[tenant]
+	if !ok {
+		ch = make(chan *request, q.maxOutstandingPerTenant)
+		q.queues[tenant] = ch
+		q.tenants = append(q.tenants, tenant)
+	}
+
+	select {
+	case ch <- r:
+		q.mu.Unlock()
+		q.cond.Broadcast()
+		return nil
+	default:
+		q.mu.Unlock()
+		return errTenantQueueFull
+	}
+}
+
+// dequeue returns the next request to hand to an idle querier worker,
+// visiting tenants round-robin starting after the last tenant served. It
+// blocks until work is available or ctx is cancelled.
+func (q *tenantQueue) dequeue(ctx context.Context) (*request, error) {
+	// cond.Wait doesn't understand context cancellation, so wake it up
+	// once when ctx is done. Broadcast must come after taking q.mu: the
+	// loop below holds q.mu the entire time it isn't inside cond.Wait, so
+	// taking the lock here first guarantees this goroutine can't fire its
+	// one-shot Broadcast until the loop is actually asleep in Wait (which
+	// atomically releases q.mu) - otherwise a Broadcast landing between
+	// the ctx.Err() check and the Wait call below is missed entirely, and
+	// nothing else wakes the loop until the next unrelated enqueue.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.mu.Unlock()
+			q.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if r, ok := q.popLocked(); ok {
+			return r, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// popLocked must be called with q.mu held. It returns the next request, if
+// any tenant has one pending.
+func (q *tenantQueue) popLocked() (*request, bool) {
+	n := len(q.tenants)
+	for i := 0; i < n; i++ {
+		idx := (q.next + i) % n
+		tenant := q.tenants[idx]
+		select {
+		case r := <-q.queues[tenant]:
+			q.next = idx + 1
+			return r, true
+		default:
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,155 @@
+package queryfrontend
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	tempolog "github.com/grafana/tempo/pkg/log"
+	"github.com/grafana/tempo/pkg/querier"
+	"github.com/grafana/tempo/pkg/queryfrontend/frontendpb"
+)
+
+// Worker runs on the querier and pulls work from a query frontend over
+// gRPC, rather than the frontend calling into the querier's own HTTP/gRPC
+// endpoints directly. Running `cfg.Parallelism` of these lets one querier
+// serve several frontend requests concurrently.
+type Worker struct {
+	cfg     WorkerConfig
+	querier *querier.Querier
+	log     tempolog.Logger
+
+	cancel context.CancelFunc
+}
+
+// NewWorker creates and starts a Worker. If cfg.FrontendAddress is unset,
+// NewWorker returns nil without error: no worker is created, which is
+// fine for a standalone querier (it serves requests directly), but in
+// -target=all it leaves the in-process frontend with nothing to dequeue
+// its requests - Frontend.handleHTTP fails fast with 503 rather than
+// hanging until the caller's context expires, so set
+// querier.frontend-address to the process's own gRPC address to use the
+// frontend's queue in that mode.
+func NewWorker(cfg WorkerConfig, q *querier.Querier, logger tempolog.Logger) (*Worker, error) {
+	if cfg.FrontendAddress == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Worker{
+		cfg:     cfg,
+		querier: q,
+		log:     logger,
+		cancel:  cancel,
+	}
+
+	for i := 0; i < cfg.Parallelism; i++ {
+		go w.run(ctx)
+	}
+
+	return w, nil
+}
+
+// Stop tells all running worker loops to disconnect from the frontend.
+func (w *Worker) Stop() {
+	if w == nil {
+		return
+	}
+	w.cancel()
+}
+
+// run dials the frontend and repeatedly pulls a request, executes it
+// against the local querier, and sends back the result, until ctx is
+// cancelled or the connection drops. On disconnect it redials with a
+// backoff rather than giving up, since the frontend (or a load balancer in
+// front of it) may come back.
+func (w *Worker) run(ctx context.Context) {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for ctx.Err() == nil {
+		if err := w.connectAndProcess(ctx); err != nil {
+			level.Warn(w.log).Log("msg", "query frontend worker disconnected, reconnecting", "addr", w.cfg.FrontendAddress, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (w *Worker) connectAndProcess(ctx context.Context) error {
+	conn, err := grpc.DialContext(ctx, w.cfg.FrontendAddress, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := frontendpb.NewFrontendClient(conn)
+	stream, err := client.Process(ctx)
+	if err != nil {
+		return err
+	}
+
+	// This loop only ends with an error. run does not reset its backoff
+	// after a successful connection: the delay before each redial keeps
+	// doubling up to maxBackoff.
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		resp := w.process(ctx, req)
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// process executes a single queued request against the local querier and
+// turns the result (or error) into a ProcessResponse.
+func (w *Worker) process(ctx context.Context, req *frontendpb.ProcessRequest) *frontendpb.ProcessResponse {
+	httpReq := &httpgrpc.HTTPRequest{}
+	if err := httpReq.Unmarshal(req.HttpBody); err != nil {
+		return &frontendpb.ProcessResponse{Error: err.Error()}
+	}
+
+	r, err := httpgrpc.ToHTTPRequest(ctx, httpReq)
+	if err != nil {
+		return &frontendpb.ProcessResponse{Error: err.Error()}
+	}
+
+	// The frontend already authenticated this request and extracted its
+	// tenant into req.TenantID; inject it back into the request context
+	// the same way the module's own auth middleware would, so
+	// TraceByIDHandler sees the tenant it would if called over HTTP
+	// directly rather than pulled through the frontend.
+	r = r.WithContext(user.InjectOrgID(r.Context(), req.TenantID))
+
+	rec := httptest.NewRecorder()
+	w.querier.TraceByIDHandler(rec, r)
+
+	httpResp := &httpgrpc.HTTPResponse{
+		Code: int32(rec.Code),
+		Body: rec.Body.Bytes(),
+	}
+	body, err := httpResp.Marshal()
+	if err != nil {
+		return &frontendpb.ProcessResponse{Error: err.Error()}
+	}
+
+	return &frontendpb.ProcessResponse{HttpBody: body}
+}
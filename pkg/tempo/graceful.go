@@ -0,0 +1,189 @@
+package tempo
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Environment variables used to pass listening sockets to a child process,
+// following the systemd socket activation convention (LISTEN_FDS start at
+// fd 3) so the same inheritance path works whether the child was started
+// by systemd or by our own re-exec below.
+const (
+	envListenFDs   = "LISTEN_FDS"
+	envListenPID   = "LISTEN_PID"
+	listenFDsStart = 3
+	selfReexecPID  = "0" // sentinel LISTEN_PID meaning "trust it, we set this ourselves"
+)
+
+// GracefulConfig configures graceful restarts and shutdowns.
+type GracefulConfig struct {
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// RegisterFlags registers flags for GracefulConfig.
+func (cfg *GracefulConfig) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.ShutdownTimeout, "server.graceful-shutdown-timeout", 30*time.Second, "Time to wait for in-flight requests to drain during a graceful restart or shutdown before forcing close.")
+}
+
+// inheritedListeners returns the TCP listeners passed down by a parent
+// process, either true systemd socket activation or our own re-exec
+// below. It returns nil, nil if none were passed, in which case the
+// caller should bind its own listeners as usual.
+func inheritedListeners() ([]*net.TCPListener, error) {
+	pidStr := os.Getenv(envListenPID)
+	nStr := os.Getenv(envListenFDs)
+	if pidStr == "" || nStr == "" {
+		return nil, nil
+	}
+
+	if pidStr != selfReexecPID {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return nil, nil
+		}
+	}
+
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]*net.TCPListener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := os.NewFile(uintptr(listenFDsStart+i), fmt.Sprintf("inherited-listener-%d", i))
+		l, err := net.FileListener(fd)
+		if err != nil {
+			return nil, fmt.Errorf("recovering inherited listener %d: %w", i, err)
+		}
+		tcp, ok := l.(*net.TCPListener)
+		if !ok {
+			return nil, fmt.Errorf("inherited listener %d is not TCP", i)
+		}
+		listeners = append(listeners, tcp)
+	}
+
+	return listeners, nil
+}
+
+// gracefulRestarter re-executes the running binary with its listening
+// sockets inherited by the child via ExtraFiles, so the child can accept
+// connections before this process stops accepting them - no bind gap.
+type gracefulRestarter struct {
+	listeners []*net.TCPListener
+}
+
+func newGracefulRestarter(listeners ...*net.TCPListener) *gracefulRestarter {
+	return &gracefulRestarter{listeners: listeners}
+}
+
+// watch installs SIGHUP/SIGUSR2 handlers and blocks until ctx is done. On
+// a restart signal it re-execs the binary and returns, leaving the caller
+// to drain and stop; on repeated signals it keeps watching so a failed
+// restart attempt doesn't wedge the process.
+func (g *gracefulRestarter) watch(ctx context.Context, onRestart func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGUSR2)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigs:
+			level.Info(util.Logger).Log("msg", "received restart signal, re-executing with inherited sockets", "signal", sig.String())
+			if err := g.reexec(); err != nil {
+				level.Error(util.Logger).Log("msg", "graceful restart failed, continuing to serve", "err", err)
+				continue
+			}
+			onRestart()
+			return
+		}
+	}
+}
+
+func (g *gracefulRestarter) reexec() error {
+	files := make([]*os.File, 0, len(g.listeners))
+	for _, l := range g.listeners {
+		f, err := l.File()
+		if err != nil {
+			return fmt.Errorf("getting fd for listener: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d", envListenFDs, len(files)),
+		fmt.Sprintf("%s=%s", envListenPID, selfReexecPID),
+	)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	level.Info(util.Logger).Log("msg", "spawned child for graceful restart", "pid", cmd.Process.Pid)
+	return nil
+}
+
+// drain runs drain hooks in reverse dependency order - the module itself
+// first, then the modules it depends on, in the order modules[m].deps
+// lists them. For All that means the ingester flushes its WAL before the
+// querier waits for its in-flight lookups; the query frontend has no drain
+// hook. This is the
+// opposite order from init/stop, which bring dependencies up (and tear
+// them down) before the modules that rely on them.
+func (t *Tempo) drain(ctx context.Context, m moduleName) {
+	t.drainModule(ctx, m, map[moduleName]struct{}{})
+}
+
+// drainModule is drain's recursive helper. visited dedups shared
+// dependencies so a module with multiple dependents isn't drained more
+// than once. Unlike stop, it doesn't mutate t.inited: Stop calls drain
+// before stop against the same target, and stop still needs an intact
+// t.inited to know what to tear down.
+func (t *Tempo) drainModule(ctx context.Context, m moduleName, visited map[moduleName]struct{}) {
+	if _, ok := visited[m]; ok {
+		return
+	}
+	visited[m] = struct{}{}
+
+	// Only a module that actually finished init has state to drain -
+	// draining one that never got past an earlier dependency's failed
+	// init would deref a nil t.ingester/t.querier/etc.
+	if _, ok := t.inited[m]; !ok {
+		return
+	}
+
+	if modules[m].drain != nil {
+		level.Info(util.Logger).Log("msg", "draining", "module", m)
+		if err := modules[m].drain(t, ctx); err != nil {
+			level.Error(util.Logger).Log("msg", "error draining", "module", m, "err", err)
+		}
+	}
+
+	for _, dep := range modules[m].deps {
+		t.drainModule(ctx, dep, visited)
+	}
+}
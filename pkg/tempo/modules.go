@@ -0,0 +1,289 @@
+package tempo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+
+	"github.com/grafana/tempo/pkg/distributor"
+	"github.com/grafana/tempo/pkg/httpapi"
+	"github.com/grafana/tempo/pkg/ingester"
+	"github.com/grafana/tempo/pkg/querier"
+	"github.com/grafana/tempo/pkg/queryfrontend"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// moduleName is the name of a module managed by Tempo's module system. Each
+// module is initialised and stopped in dependency order.
+type moduleName int
+
+// The set of modules Tempo can run. -target selects which of these actually
+// get started; All runs the full read+write path in-process.
+const (
+	Server moduleName = iota
+	Ring
+	Distributor
+	Ingester
+	Querier
+	QueryFrontend
+	All
+)
+
+func (m moduleName) String() string {
+	switch m {
+	case Server:
+		return "server"
+	case Ring:
+		return "ring"
+	case Distributor:
+		return "distributor"
+	case Ingester:
+		return "ingester"
+	case Querier:
+		return "querier"
+	case QueryFrontend:
+		return "query-frontend"
+	case All:
+		return "all"
+	default:
+		return "unknown"
+	}
+}
+
+// Set implements flag.Value so moduleName can be used directly as a -target
+// flag.
+func (m *moduleName) Set(s string) error {
+	switch s {
+	case "server":
+		*m = Server
+	case "ring":
+		*m = Ring
+	case "distributor":
+		*m = Distributor
+	case "ingester":
+		*m = Ingester
+	case "querier":
+		*m = Querier
+	case "query-frontend":
+		*m = QueryFrontend
+	case "all":
+		*m = All
+	default:
+		return fmt.Errorf("unrecognised module name: %s", s)
+	}
+	return nil
+}
+
+type module struct {
+	deps  []moduleName
+	init  func(t *Tempo) error
+	drain func(t *Tempo, ctx context.Context) error
+	stop  func(t *Tempo) error
+}
+
+var modules = map[moduleName]module{
+	Server: {
+		init: (*Tempo).initServer,
+	},
+
+	Ring: {
+		deps: []moduleName{Server},
+		init: (*Tempo).initRing,
+	},
+
+	Distributor: {
+		deps: []moduleName{Ring, Server},
+		init: (*Tempo).initDistributor,
+	},
+
+	Ingester: {
+		deps:  []moduleName{Server},
+		init:  (*Tempo).initIngester,
+		drain: (*Tempo).drainIngester,
+		stop:  (*Tempo).stopIngester,
+	},
+
+	Querier: {
+		deps:  []moduleName{Ring, Server},
+		init:  (*Tempo).initQuerier,
+		drain: (*Tempo).drainQuerier,
+		stop:  (*Tempo).stopQuerier,
+	},
+
+	// QueryFrontend runs the HTTP+gRPC frontend that queriers pull work
+	// from. It has no dependency on the ring: a frontend doesn't need to
+	// know about ingesters, only about the queriers dialed in as workers.
+	QueryFrontend: {
+		deps: []moduleName{Server},
+		init: (*Tempo).initQueryFrontend,
+		stop: (*Tempo).stopQueryFrontend,
+	},
+
+	All: {
+		deps: []moduleName{Distributor, Ingester, Querier, QueryFrontend},
+	},
+}
+
+func (t *Tempo) initServer() error {
+	// If we were started under systemd socket activation, or re-exec'd by
+	// a parent Tempo doing a graceful restart, inherit its listeners
+	// instead of binding fresh ones - this is what avoids the bind gap.
+	listeners, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+	if len(listeners) > 0 {
+		t.cfg.Server.HTTPListener = listeners[0]
+	}
+	if len(listeners) > 1 {
+		t.cfg.Server.GRPCListener = listeners[1]
+	}
+
+	server, err := server.New(t.cfg.Server)
+	if err != nil {
+		return err
+	}
+
+	t.server = server
+	return nil
+}
+
+func (t *Tempo) initRing() error {
+	r, err := ring.New(t.cfg.Ingester.LifecyclerConfig.RingConfig, "ingester", "collectors/ring")
+	if err != nil {
+		return err
+	}
+
+	t.ring = r
+	t.server.HTTP.Handle("/ring", t.ring)
+	return nil
+}
+
+func (t *Tempo) initDistributor() error {
+	d, err := distributor.New(t.cfg.Distributor, t.cfg.IngesterClient, t.ring)
+	if err != nil {
+		return err
+	}
+
+	t.distributor = d
+	return nil
+}
+
+func (t *Tempo) initIngester() error {
+	i, err := ingester.New(t.cfg.Ingester)
+	if err != nil {
+		return err
+	}
+
+	t.ingester = i
+
+	// Expose the same push path over HTTP+JSON as a supported entry
+	// point for non-gRPC clients (curl, browsers, load generators),
+	// going through the real gRPC handler - and its auth interceptor -
+	// via an in-process connection rather than grpc-gateway. Dialing here
+	// is safe (it only registers a pending connection); actually serving
+	// it is deferred until every module has finished registering its own
+	// gRPC services - see Tempo.New.
+	conn, start, closeConn, err := httpapi.NewLocalConn(context.Background(), t.server.GRPC)
+	if err != nil {
+		return err
+	}
+	t.ingesterLocalConnClose = closeConn
+	t.localConnStarts = append(t.localConnStarts, start)
+	httpapi.RegisterIngesterPushHTTP(t.server.HTTP, t.httpAuthMiddleware, tempopb.NewPusherClient(conn))
+
+	return nil
+}
+
+// drainIngester flushes the write-ahead log to durable storage before the
+// ingester is torn down, so a graceful restart/upgrade doesn't lose
+// in-flight trace data.
+func (t *Tempo) drainIngester(ctx context.Context) error {
+	return t.ingester.FlushWAL(ctx)
+}
+
+func (t *Tempo) stopIngester() error {
+	t.ingester.Shutdown()
+	if t.ingesterLocalConnClose != nil {
+		t.ingesterLocalConnClose()
+	}
+	return nil
+}
+
+func (t *Tempo) initQuerier() error {
+	q, err := querier.New(t.cfg.Querier, t.cfg.IngesterClient, t.ring)
+	if err != nil {
+		return err
+	}
+	t.querier = q
+
+	// Register a worker that dials the query frontend and pulls work
+	// from it instead of waiting for the frontend to call in, whenever
+	// there is a frontend for it to pull from: either a
+	// standalone querier target pointed at a separate frontend, or -target
+	// all running both in-process.
+	if t.cfg.Target == Querier || t.cfg.Target == All {
+		worker, err := queryfrontend.NewWorker(t.cfg.Worker, q, util.Logger)
+		if err != nil {
+			return err
+		}
+		t.frontendWorker = worker
+	}
+
+	// Only register the querier's own /api/traces gateway when this
+	// process doesn't also run a query frontend in-process: in -target=all
+	// the frontend's own route (registered in initQueryFrontend) owns
+	// this path instead, so lookups go through the tenant queue and the
+	// worker above rather than bypassing both via a direct call straight
+	// into this querier. gorilla/mux serves whichever handler registered
+	// first, so leaving both registered would silently make this one win
+	// and the frontend dead code.
+	if t.cfg.Target != All {
+		conn, start, closeConn, err := httpapi.NewLocalConn(context.Background(), t.server.GRPC)
+		if err != nil {
+			return err
+		}
+		t.querierLocalConnClose = closeConn
+		t.localConnStarts = append(t.localConnStarts, start)
+		httpapi.RegisterQuerierHTTP(t.server.HTTP, t.httpAuthMiddleware, tempopb.NewQuerierClient(conn))
+	}
+
+	return nil
+}
+
+// drainQuerier waits for in-flight trace lookups to finish, up to ctx's
+// deadline, before the querier (and the frontend worker pulling work into
+// it) is stopped.
+func (t *Tempo) drainQuerier(ctx context.Context) error {
+	return t.querier.Drain(ctx)
+}
+
+func (t *Tempo) stopQuerier() error {
+	t.frontendWorker.Stop()
+	if t.querierLocalConnClose != nil {
+		t.querierLocalConnClose()
+	}
+	return nil
+}
+
+func (t *Tempo) initQueryFrontend() error {
+	f, err := queryfrontend.New(t.cfg.QueryFrontend, t.httpAuthMiddleware, util.Logger)
+	if err != nil {
+		return err
+	}
+
+	t.frontend = f
+	f.RegisterRoutes(t.server.HTTP)
+	f.RegisterGRPC(t.server.GRPC)
+	return nil
+}
+
+func (t *Tempo) stopQueryFrontend() error {
+	if t.frontend == nil {
+		return nil
+	}
+	t.frontend.Shutdown()
+	return nil
+}
@@ -1,12 +1,14 @@
 package tempo
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
+	"sync/atomic"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
-	"google.golang.org/grpc"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/util"
@@ -16,19 +18,25 @@ import (
 	"github.com/grafana/tempo/pkg/distributor"
 	"github.com/grafana/tempo/pkg/ingester"
 	"github.com/grafana/tempo/pkg/ingester/client"
+	tempolog "github.com/grafana/tempo/pkg/log"
 	"github.com/grafana/tempo/pkg/querier"
+	"github.com/grafana/tempo/pkg/queryfrontend"
 )
 
 // Config is the root config for Tempo.
 type Config struct {
-	Target      moduleName `yaml:"target,omitempty"`
-	AuthEnabled bool       `yaml:"auth_enabled,omitempty"`
-
-	Server         server.Config      `yaml:"server,omitempty"`
-	Distributor    distributor.Config `yaml:"distributor,omitempty"`
-	Querier        querier.Config     `yaml:"querier,omitempty"`
-	IngesterClient client.Config      `yaml:"ingester_client,omitempty"`
-	Ingester       ingester.Config    `yaml:"ingester,omitempty"`
+	Target   moduleName      `yaml:"target,omitempty"`
+	Auth     AuthConfig      `yaml:"auth,omitempty"`
+	Graceful GracefulConfig  `yaml:"graceful,omitempty"`
+	Log      tempolog.Config `yaml:"log,omitempty"`
+
+	Server         server.Config             `yaml:"server,omitempty"`
+	Distributor    distributor.Config        `yaml:"distributor,omitempty"`
+	Querier        querier.Config            `yaml:"querier,omitempty"`
+	IngesterClient client.Config             `yaml:"ingester_client,omitempty"`
+	Ingester       ingester.Config           `yaml:"ingester,omitempty"`
+	QueryFrontend  queryfrontend.Config       `yaml:"query_frontend,omitempty"`
+	Worker         queryfrontend.WorkerConfig `yaml:"querier_worker,omitempty"`
 }
 
 // RegisterFlags registers flag.
@@ -36,64 +44,99 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.Server.MetricsNamespace = "tempo"
 	c.Target = All
 	f.Var(&c.Target, "target", "target module (default All)")
-	f.BoolVar(&c.AuthEnabled, "auth.enabled", true, "Set to false to disable auth.")
+	c.Auth.RegisterFlags(f)
+	c.Graceful.RegisterFlags(f)
+	c.Log.RegisterFlags(f)
 
 	c.Server.RegisterFlags(f)
 	c.Distributor.RegisterFlags(f)
 	c.Querier.RegisterFlags(f)
 	c.IngesterClient.RegisterFlags(f)
 	c.Ingester.RegisterFlags(f)
+	c.QueryFrontend.RegisterFlags(f)
+	c.Worker.RegisterFlags(f)
 }
 
 // Tempo is the root datastructure for Tempo.
 type Tempo struct {
 	cfg Config
 
-	server      *server.Server
-	ring        *ring.Ring
-	distributor *distributor.Distributor
-	ingester    *ingester.Ingester
-	querier     *querier.Querier
+	server         *server.Server
+	ring           *ring.Ring
+	distributor    *distributor.Distributor
+	ingester       *ingester.Ingester
+	querier        *querier.Querier
+	frontend       *queryfrontend.Frontend
+	frontendWorker *queryfrontend.Worker
 
 	httpAuthMiddleware middleware.Interface
 
+	logger       tempolog.Logger
+	moduleLevels map[string]string
+
 	inited map[moduleName]struct{}
+
+	// localConnStarts begins serving t.server.GRPC on the in-memory
+	// listeners modules dialed during init (see httpapi.NewLocalConn).
+	// They're collected here rather than called from init directly
+	// because t.server.GRPC must finish *all* of its RegisterService
+	// calls, across every module init runs for this target, before any
+	// of them is allowed to start serving.
+	localConnStarts []func()
+
+	ingesterLocalConnClose func()
+	querierLocalConnClose  func()
 }
 
 // New makes a new Tempo.
 func New(cfg Config) (*Tempo, error) {
+	logger, err := tempolog.New(cfg.Log)
+	if err != nil {
+		return nil, errors.Wrap(err, "initialising logger")
+	}
+	util.Logger = logger
+
+	moduleLevels, err := tempolog.ParseModuleLevels(cfg.Log.ModuleLevels)
+	if err != nil {
+		return nil, err
+	}
+
 	tempo := &Tempo{
-		cfg:    cfg,
-		inited: map[moduleName]struct{}{},
+		cfg:          cfg,
+		logger:       logger,
+		moduleLevels: moduleLevels,
+		inited:       map[moduleName]struct{}{},
 	}
 
-	tempo.setupAuthMiddleware()
+	if err := tempo.setupAuthMiddleware(); err != nil {
+		return nil, err
+	}
 
 	if err := tempo.init(cfg.Target); err != nil {
 		return nil, err
 	}
 
+	// Every module for this target has registered its gRPC services on
+	// t.server.GRPC by now, regardless of init order, so it's safe to
+	// start serving the in-memory connections those modules dialed
+	// during init - doing this any earlier risks a module registering
+	// after another has already called Serve.
+	for _, start := range tempo.localConnStarts {
+		start()
+	}
+
 	return tempo, nil
 }
 
-func (t *Tempo) setupAuthMiddleware() {
-	if t.cfg.AuthEnabled {
-		t.cfg.Server.GRPCMiddleware = []grpc.UnaryServerInterceptor{
-			middleware.ServerUserHeaderInterceptor,
-		}
-		t.cfg.Server.GRPCStreamMiddleware = []grpc.StreamServerInterceptor{
-			middleware.StreamServerUserHeaderInterceptor,
-		}
-		t.httpAuthMiddleware = middleware.AuthenticateUser
-	} else {
-		t.cfg.Server.GRPCMiddleware = []grpc.UnaryServerInterceptor{
-			fakeGRPCAuthUniaryMiddleware,
-		}
-		t.cfg.Server.GRPCStreamMiddleware = []grpc.StreamServerInterceptor{
-			fakeGRPCAuthStreamMiddleware,
-		}
-		t.httpAuthMiddleware = fakeHTTPAuthMiddleware
+// loggerFor returns the logger a module's init/stop hooks should log
+// through: the base logger, or an override if log.module-levels names
+// this module.
+func (t *Tempo) loggerFor(m moduleName) (tempolog.Logger, error) {
+	lvl, ok := t.moduleLevels[m.String()]
+	if !ok {
+		return t.logger, nil
 	}
+	return tempolog.WithLevel(t.logger, lvl)
 }
 
 func (t *Tempo) init(m moduleName) error {
@@ -107,6 +150,14 @@ func (t *Tempo) init(m moduleName) error {
 		}
 	}
 
+	moduleLogger, err := t.loggerFor(m)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("resolving log level for module: %s", m))
+	}
+	prevLogger := util.Logger
+	util.Logger = moduleLogger
+	defer func() { util.Logger = prevLogger }()
+
 	level.Info(util.Logger).Log("msg", "initialising", "module", m)
 	if modules[m].init != nil {
 		if err := modules[m].init(t); err != nil {
@@ -118,14 +169,42 @@ func (t *Tempo) init(m moduleName) error {
 	return nil
 }
 
-// Run starts Tempo running, and blocks until a signal is received.
+// Run starts Tempo running, and blocks until a signal is received or a
+// SIGHUP/SIGUSR2 triggers a graceful restart.
 func (t *Tempo) Run() error {
-	return t.server.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var restarting int32
+	if listener, ok := t.server.HTTPListener.(*net.TCPListener); ok {
+		restarter := newGracefulRestarter(listener)
+		if grpcListener, ok := t.server.GRPCListener.(*net.TCPListener); ok {
+			restarter.listeners = append(restarter.listeners, grpcListener)
+		}
+		go restarter.watch(ctx, func() {
+			atomic.StoreInt32(&restarting, 1)
+			t.server.Shutdown()
+		})
+	}
+
+	err := t.server.Run()
+	cancel()
+	if atomic.LoadInt32(&restarting) == 1 {
+		return nil
+	}
+	return err
 }
 
-// Stop gracefully stops a Tempo.
+// Stop gracefully stops Tempo: it stops accepting new connections, drains
+// in-flight work up to the configured timeout, and only then tears
+// modules down.
 func (t *Tempo) Stop() error {
 	t.server.Shutdown()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), t.cfg.Graceful.ShutdownTimeout)
+	defer cancel()
+	t.drain(drainCtx, t.cfg.Target)
+
 	t.stop(t.cfg.Target)
 	return nil
 }
@@ -144,6 +223,14 @@ func (t *Tempo) stop(m moduleName) {
 		return
 	}
 
+	moduleLogger, err := t.loggerFor(m)
+	if err != nil {
+		moduleLogger = t.logger
+	}
+	prevLogger := util.Logger
+	util.Logger = moduleLogger
+	defer func() { util.Logger = prevLogger }()
+
 	level.Info(util.Logger).Log("msg", "stopping", "module", m)
 	if err := modules[m].stop(t); err != nil {
 		level.Error(util.Logger).Log("msg", "error stopping", "module", m, "err", err)